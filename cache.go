@@ -0,0 +1,113 @@
+package zseek
+
+import (
+	"container/list"
+	"io"
+)
+
+// NewCached is like NewCodec, but wraps z in an LRU cache of up to cacheBytes of decompressed
+// chunk data, keyed by each chunk's physical header offset. fill consults the cache before
+// decompressing a chunk, so a Seek followed by small Reads within the same chunk -- or a
+// later seek back to a chunk already visited -- doesn't re-pay the decompression cost, the
+// same locality of reference that makes zip and pack readers cache central-directory-indexed
+// entries. See CacheStats for hit, miss, and eviction counters.
+func NewCached(f io.ReadWriteSeeker, codec Codec, level, buf, cacheBytes int) (*ZSeek, error) {
+	z, err := NewCodec(f, codec, level, buf)
+	if err != nil {
+		return nil, err
+	}
+	z.cache = newChunkCache(int64(cacheBytes))
+	return z, nil
+}
+
+// CacheStats reports how a ZSeek's chunk cache has performed so far.
+type CacheStats struct {
+	Hits      int64
+	Misses    int64
+	Evictions int64
+}
+
+// CacheStats returns z's cache hit, miss, and eviction counts. It returns the zero value if z
+// was not created with NewCached. Like ReadAt, CacheStats may be called concurrently with
+// other calls to CacheStats or ReadAt, since it takes the same lock fill uses to update the
+// cache.
+func (z *ZSeek) CacheStats() CacheStats {
+	if z.cache == nil {
+		return CacheStats{}
+	}
+
+	z.ioMu.Lock()
+	defer z.ioMu.Unlock()
+	return CacheStats{Hits: z.cache.hits, Misses: z.cache.misses, Evictions: z.cache.evictions}
+}
+
+// cacheEntry is one decompressed chunk held by a chunkCache, keyed by the chunk's physical
+// header offset. nextPhys is the physical offset of the chunk that follows it, so a cache hit
+// in fill can advance z.pos.phys without re-reading the chunk's header.
+type cacheEntry struct {
+	phys     int64
+	nextPhys int64
+	data     []byte
+}
+
+// chunkCache is an LRU cache of decompressed chunks, evicting the least recently used entry
+// once the total size of cached data would exceed maxBytes.
+type chunkCache struct {
+	maxBytes int64
+	curBytes int64
+	ll       *list.List
+	items    map[int64]*list.Element
+
+	hits, misses, evictions int64
+}
+
+func newChunkCache(maxBytes int64) *chunkCache {
+	return &chunkCache{
+		maxBytes: maxBytes,
+		ll:       list.New(),
+		items:    make(map[int64]*list.Element),
+	}
+}
+
+// get returns the cached decompressed data for the chunk starting at phys, along with the
+// physical offset of the following chunk, moving it to the front of the LRU list.
+func (c *chunkCache) get(phys int64) (data []byte, nextPhys int64, ok bool) {
+	el, ok := c.items[phys]
+	if !ok {
+		c.misses++
+		return nil, 0, false
+	}
+	c.hits++
+	c.ll.MoveToFront(el)
+	e := el.Value.(*cacheEntry)
+	return e.data, e.nextPhys, true
+}
+
+// put caches data as the decompressed contents of the chunk starting at phys, followed by the
+// chunk at nextPhys, evicting the least recently used entries until the cache is back under
+// maxBytes. A single entry larger than maxBytes is not cached.
+func (c *chunkCache) put(phys, nextPhys int64, data []byte) {
+	if c.maxBytes <= 0 || int64(len(data)) > c.maxBytes {
+		return
+	}
+
+	if el, ok := c.items[phys]; ok {
+		c.curBytes -= int64(len(el.Value.(*cacheEntry).data))
+		el.Value = &cacheEntry{phys: phys, nextPhys: nextPhys, data: data}
+		c.curBytes += int64(len(data))
+		c.ll.MoveToFront(el)
+	} else {
+		el := c.ll.PushFront(&cacheEntry{phys: phys, nextPhys: nextPhys, data: data})
+		c.items[phys] = el
+		c.curBytes += int64(len(data))
+	}
+
+	for c.curBytes > c.maxBytes {
+		back := c.ll.Back()
+		e := back.Value.(*cacheEntry)
+		c.ll.Remove(back)
+		delete(c.items, e.phys)
+		c.curBytes -= int64(len(e.data))
+		c.evictions++
+	}
+}
@@ -0,0 +1,118 @@
+package zseek
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestCachedFillHitsAndEvicts(t *testing.T) {
+	f, err := ioutil.TempFile(os.TempDir(), "zseek")
+	if err != nil {
+		t.Skip("temp file creation failed: ", err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	chunks := [][]byte{
+		bytes.Repeat([]byte("a"), 4096),
+		bytes.Repeat([]byte("b"), 4096),
+		bytes.Repeat([]byte("c"), 4096),
+	}
+
+	z, err := New(f)
+	if err != nil {
+		t.Fatal("New: ", err)
+	}
+	for _, c := range chunks {
+		if _, err = z.Write(c); err != nil {
+			t.Fatal("Write: ", err)
+		}
+		if err = z.Flush(); err != nil {
+			t.Fatal("Flush: ", err)
+		}
+	}
+	if err = z.Close(); err != nil {
+		t.Fatal("Close: ", err)
+	}
+
+	// cache only big enough for two of the three chunks, to exercise eviction.
+	zc, err := NewCached(f, ZlibCodec, DefaultCompression, DefaultBuffer, 2*4096)
+	if err != nil {
+		t.Fatal("NewCached: ", err)
+	}
+	defer zc.Close()
+
+	got := make([]byte, 4096)
+	for i := range chunks {
+		if _, err = zc.Seek(int64(i*4096), SeekStart); err != nil {
+			t.Fatal("Seek: ", err)
+		}
+		if _, err = zc.Read(got); err != nil {
+			t.Fatal("Read: ", err)
+		}
+		if !bytes.Equal(got, chunks[i]) {
+			t.Error("chunk ", i, " did not round-trip")
+		}
+	}
+
+	stats := zc.CacheStats()
+	if stats.Misses != 3 {
+		t.Error("expected 3 misses, but got ", stats.Misses)
+	}
+
+	// re-reading the most recently filled chunk should now hit the cache.
+	if _, err = zc.Seek(2*4096, SeekStart); err != nil {
+		t.Fatal("Seek: ", err)
+	}
+	if _, err = zc.Read(got); err != nil {
+		t.Fatal("Read: ", err)
+	}
+	if !bytes.Equal(got, chunks[2]) {
+		t.Error("re-read chunk did not match")
+	}
+
+	stats = zc.CacheStats()
+	if stats.Hits == 0 {
+		t.Error("expected at least 1 hit, but got 0")
+	}
+	if stats.Evictions == 0 {
+		t.Error("expected at least 1 eviction, but got 0")
+	}
+}
+
+// TestNewCachedWithCodec confirms NewCached composes with a non-default codec, rather than
+// always writing zlib-compressed chunks regardless of the codec argument.
+func TestNewCachedWithCodec(t *testing.T) {
+	f, err := ioutil.TempFile(os.TempDir(), "zseek")
+	if err != nil {
+		t.Skip("temp file creation failed: ", err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	z, err := NewCached(f, ZstdCodec, DefaultCompression, DefaultBuffer, 4096)
+	if err != nil {
+		t.Fatal("NewCached: ", err)
+	}
+
+	data := bytes.Repeat([]byte("cached zstd data"), 256)
+	if _, err = z.Write(data); err != nil {
+		t.Fatal("Write: ", err)
+	}
+	if err = z.Close(); err != nil {
+		t.Fatal("Close: ", err)
+	}
+
+	if _, err = f.Seek(0, SeekStart); err != nil {
+		t.Fatal("Seek: ", err)
+	}
+	_, codec, _, err := readChunkHeader(f, 0)
+	if err != nil {
+		t.Fatal("readChunkHeader: ", err)
+	}
+	if codec.ID() != ZstdCodec.ID() {
+		t.Error("expected the first chunk to be tagged zstd, but got codec id ", codec.ID())
+	}
+}
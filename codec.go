@@ -0,0 +1,175 @@
+package zseek
+
+import (
+	"compress/zlib"
+	"io"
+	"io/ioutil"
+	"strconv"
+
+	"github.com/andybalholm/brotli"
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+	lz4 "github.com/pierrec/lz4/v4"
+)
+
+// Codec IDs persisted in the on-disk chunk header. These values are part of the file
+// format: once released, an ID must keep meaning the same algorithm forever.
+const (
+	codecZlib uint8 = iota
+	codecZstd
+	codecLZ4
+	codecSnappy
+	codecBrotli
+)
+
+// UnknownCodecError is returned when a chunk's persisted codec ID does not match any codec
+// registered with RegisterCodec.
+type UnknownCodecError struct {
+	ID uint8
+}
+
+func (e UnknownCodecError) Error() string {
+	return "zseek: unknown codec id " + strconv.Itoa(int(e.ID))
+}
+
+// Codec implements a compression algorithm that ZSeek can use to compress and decompress
+// chunks. The built-in codecs are ZlibCodec, ZstdCodec, LZ4Codec, SnappyCodec, and
+// BrotliCodec.
+type Codec interface {
+	// ID returns the one-byte identifier persisted alongside each chunk so that fill can
+	// dispatch to the right decoder even for a file whose chunks were written by
+	// different codecs over time.
+	ID() uint8
+
+	// NewWriter returns a writer that compresses to w at the given level. level follows
+	// the conventions of NewLevel; codecs without a notion of level ignore it.
+	NewWriter(w io.Writer, level int) (io.WriteCloser, error)
+
+	// NewReader returns a reader that decompresses r.
+	NewReader(r io.Reader) (io.ReadCloser, error)
+}
+
+// Built-in codecs, usable as the codec argument to NewCodec.
+var (
+	ZlibCodec   Codec = zlibCodec{}
+	ZstdCodec   Codec = zstdCodec{}
+	LZ4Codec    Codec = lz4Codec{}
+	SnappyCodec Codec = snappyCodec{}
+	BrotliCodec Codec = brotliCodec{}
+)
+
+// codecByID maps a persisted codec ID back to the Codec that can decode it. Callers that
+// define their own Codec with a custom ID should add it here before opening files that use
+// it.
+var codecByID = map[uint8]Codec{
+	ZlibCodec.ID():   ZlibCodec,
+	ZstdCodec.ID():   ZstdCodec,
+	LZ4Codec.ID():    LZ4Codec,
+	SnappyCodec.ID(): SnappyCodec,
+	BrotliCodec.ID(): BrotliCodec,
+}
+
+// RegisterCodec makes codec available for decoding chunks tagged with its ID. It is not
+// safe to call RegisterCodec concurrently with opening a ZSeek.
+func RegisterCodec(codec Codec) {
+	codecByID[codec.ID()] = codec
+}
+
+type zlibCodec struct{}
+
+func (zlibCodec) ID() uint8 { return codecZlib }
+
+func (zlibCodec) NewWriter(w io.Writer, level int) (io.WriteCloser, error) {
+	return zlib.NewWriterLevel(w, level)
+}
+
+func (zlibCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return zlib.NewReader(r)
+}
+
+type zstdCodec struct{}
+
+func (zstdCodec) ID() uint8 { return codecZstd }
+
+func (zstdCodec) NewWriter(w io.Writer, level int) (io.WriteCloser, error) {
+	var zl zstd.EncoderLevel
+	if level == DefaultCompression {
+		zl = zstd.SpeedDefault
+	} else {
+		zl = zstd.EncoderLevelFromZstd(level)
+	}
+	return zstd.NewWriter(w, zstd.WithEncoderLevel(zl))
+}
+
+func (zstdCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	d, err := zstd.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	return d.IOReadCloser(), nil
+}
+
+type lz4Codec struct{}
+
+func (lz4Codec) ID() uint8 { return codecLZ4 }
+
+func (lz4Codec) NewWriter(w io.Writer, level int) (io.WriteCloser, error) {
+	lw := lz4.NewWriter(w)
+	if err := lw.Apply(lz4.CompressionLevelOption(lz4Level(level))); err != nil {
+		return nil, err
+	}
+	return lw, nil
+}
+
+func (lz4Codec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return ioutil.NopCloser(lz4.NewReader(r)), nil
+}
+
+// lz4Level maps a zlib-style level (NoCompression..BestCompression, or
+// DefaultCompression) onto the nearest lz4.CompressionLevel.
+func lz4Level(level int) lz4.CompressionLevel {
+	switch {
+	case level == DefaultCompression:
+		return lz4.Level5
+	case level < int(lz4.Fast):
+		return lz4.Fast
+	case level > 9:
+		return lz4.Level9
+	}
+	return []lz4.CompressionLevel{
+		lz4.Fast,
+		lz4.Level1, lz4.Level2, lz4.Level3, lz4.Level4,
+		lz4.Level5, lz4.Level6, lz4.Level7, lz4.Level8, lz4.Level9,
+	}[level]
+}
+
+type snappyCodec struct{}
+
+func (snappyCodec) ID() uint8 { return codecSnappy }
+
+func (snappyCodec) NewWriter(w io.Writer, level int) (io.WriteCloser, error) {
+	return snappy.NewBufferedWriter(w), nil
+}
+
+func (snappyCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return ioutil.NopCloser(snappy.NewReader(r)), nil
+}
+
+type brotliCodec struct{}
+
+func (brotliCodec) ID() uint8 { return codecBrotli }
+
+func (brotliCodec) NewWriter(w io.Writer, level int) (io.WriteCloser, error) {
+	if level == DefaultCompression {
+		level = brotli.DefaultCompression
+	} else if level < brotli.BestSpeed {
+		level = brotli.BestSpeed
+	} else if level > brotli.BestCompression {
+		level = brotli.BestCompression
+	}
+	return brotli.NewWriterLevel(w, level), nil
+}
+
+func (brotliCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return ioutil.NopCloser(brotli.NewReader(r)), nil
+}
@@ -0,0 +1,242 @@
+package zseek
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestCodecs(t *testing.T) {
+	data := bytes.Repeat([]byte("the quick brown fox jumps over the lazy dog"), 1024)
+
+	for _, codec := range []Codec{ZlibCodec, ZstdCodec, LZ4Codec, SnappyCodec, BrotliCodec} {
+		codec := codec
+		t.Run(codecName(codec), func(t *testing.T) {
+			var buf bytes.Buffer
+			w, err := codec.NewWriter(&buf, DefaultCompression)
+			if err != nil {
+				t.Fatal("creating writer: ", err)
+			}
+			if _, err := w.Write(data); err != nil {
+				t.Fatal("writing: ", err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal("closing writer: ", err)
+			}
+
+			r, err := codec.NewReader(&buf)
+			if err != nil {
+				t.Fatal("creating reader: ", err)
+			}
+			got, err := ioutil.ReadAll(r)
+			if err != nil {
+				t.Fatal("reading: ", err)
+			}
+			if err := r.Close(); err != nil {
+				t.Fatal("closing reader: ", err)
+			}
+
+			if !bytes.Equal(got, data) {
+				t.Error("round-tripped data did not match input")
+			}
+		})
+	}
+}
+
+// TestZstdDefaultCompressionLevel pins ZstdCodec's handling of DefaultCompression to
+// zstd.SpeedDefault rather than whatever zstd.EncoderLevelFromZstd(-1) happens to resolve to
+// (zstd.SpeedFastest, the weakest preset), matching how LZ4Codec and BrotliCodec treat
+// DefaultCompression as each library's own real default.
+func TestZstdDefaultCompressionLevel(t *testing.T) {
+	data := bytes.Repeat([]byte("the quick brown fox jumps over the lazy dog"), 1024)
+
+	var got bytes.Buffer
+	w, err := ZstdCodec.NewWriter(&got, DefaultCompression)
+	if err != nil {
+		t.Fatal("creating writer: ", err)
+	}
+	if _, err = w.Write(data); err != nil {
+		t.Fatal("writing: ", err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal("closing writer: ", err)
+	}
+
+	var want bytes.Buffer
+	ww, err := zstd.NewWriter(&want, zstd.WithEncoderLevel(zstd.SpeedDefault))
+	if err != nil {
+		t.Fatal("creating reference writer: ", err)
+	}
+	if _, err = ww.Write(data); err != nil {
+		t.Fatal("writing reference: ", err)
+	}
+	if err = ww.Close(); err != nil {
+		t.Fatal("closing reference writer: ", err)
+	}
+
+	if !bytes.Equal(got.Bytes(), want.Bytes()) {
+		t.Error("ZstdCodec's DefaultCompression did not compress like zstd.SpeedDefault")
+	}
+}
+
+// TestChunkHeaderErrors writes one real chunk and then corrupts its header's version byte,
+// length field, and codec byte in turn, checking that fill reports ChunkVersionError,
+// ChunkCorruptError, and UnknownCodecError respectively instead of misreading the chunk.
+func TestChunkHeaderErrors(t *testing.T) {
+	t.Run("version", func(t *testing.T) {
+		z, cleanup := testSetup(t)
+		defer cleanup()
+
+		if _, err := z.Write([]byte("hello")); err != nil {
+			t.Fatal("Write: ", err)
+		}
+		if err := z.Flush(); err != nil {
+			t.Fatal("Flush: ", err)
+		}
+		if _, err := z.f.Seek(0, SeekStart); err != nil {
+			t.Fatal("Seek: ", err)
+		}
+		if _, err := z.f.Write([]byte{chunkFormatVersion + 1}); err != nil {
+			t.Fatal("Write: ", err)
+		}
+
+		z2, err := New(z.f)
+		if err != nil {
+			t.Fatal("New: ", err)
+		}
+		_, err = z2.Read(make([]byte, 5))
+		if _, ok := err.(ChunkVersionError); !ok {
+			t.Error("expected a ChunkVersionError, but got ", err)
+		}
+	})
+
+	t.Run("length", func(t *testing.T) {
+		z, cleanup := testSetup(t)
+		defer cleanup()
+
+		if _, err := z.Write([]byte("hello")); err != nil {
+			t.Fatal("Write: ", err)
+		}
+		if err := z.Flush(); err != nil {
+			t.Fatal("Flush: ", err)
+		}
+		if _, err := z.f.Seek(1, SeekStart); err != nil {
+			t.Fatal("Seek: ", err)
+		}
+		// set the high bit of the length field, so it decodes as a negative int64 if
+		// read without a bounds check.
+		if _, err := z.f.Write([]byte{0, 0, 0, 0, 0, 0, 0, 0x80}); err != nil {
+			t.Fatal("Write: ", err)
+		}
+
+		z2, err := New(z.f)
+		if err != nil {
+			t.Fatal("New: ", err)
+		}
+		_, err = z2.Read(make([]byte, 5))
+		if _, ok := err.(ChunkCorruptError); !ok {
+			t.Error("expected a ChunkCorruptError, but got ", err)
+		}
+	})
+
+	t.Run("codec", func(t *testing.T) {
+		z, cleanup := testSetup(t)
+		defer cleanup()
+
+		if _, err := z.Write([]byte("hello")); err != nil {
+			t.Fatal("Write: ", err)
+		}
+		if err := z.Flush(); err != nil {
+			t.Fatal("Flush: ", err)
+		}
+		if _, err := z.f.Seek(9, SeekStart); err != nil {
+			t.Fatal("Seek: ", err)
+		}
+		if _, err := z.f.Write([]byte{0xff}); err != nil {
+			t.Fatal("Write: ", err)
+		}
+
+		z2, err := New(z.f)
+		if err != nil {
+			t.Fatal("New: ", err)
+		}
+		_, err = z2.Read(make([]byte, 5))
+		if unknown, ok := err.(UnknownCodecError); !ok {
+			t.Error("expected an UnknownCodecError, but got ", err)
+		} else if unknown.ID != 0xff {
+			t.Error("expected UnknownCodecError.ID 0xff, but got ", unknown.ID)
+		}
+	})
+}
+
+// customCodec is a trivial RegisterCodec-registered Codec used to confirm a codec outside the
+// built-in set can be used to write and read back a ZSeek's chunks.
+type customCodec struct{}
+
+func (customCodec) ID() uint8 { return 0xf0 }
+
+func (customCodec) NewWriter(w io.Writer, level int) (io.WriteCloser, error) {
+	return ZlibCodec.NewWriter(w, level)
+}
+
+func (customCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return ZlibCodec.NewReader(r)
+}
+
+func TestRegisterCodec(t *testing.T) {
+	RegisterCodec(customCodec{})
+	defer delete(codecByID, customCodec{}.ID())
+
+	f, err := ioutil.TempFile(os.TempDir(), "zseek")
+	if err != nil {
+		t.Skip("temp file creation failed: ", err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	z, err := NewCodec(f, customCodec{}, DefaultCompression, DefaultBuffer)
+	if err != nil {
+		t.Fatal("NewCodec: ", err)
+	}
+
+	data := []byte("round-trip through a custom codec")
+	if _, err = z.Write(data); err != nil {
+		t.Fatal("Write: ", err)
+	}
+	if err = z.Close(); err != nil {
+		t.Fatal("Close: ", err)
+	}
+
+	z2, err := New(f)
+	if err != nil {
+		t.Fatal("New: ", err)
+	}
+	got := make([]byte, len(data))
+	if _, err = io.ReadFull(z2, got); err != nil {
+		t.Fatal("ReadFull: ", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Error("round-tripped data did not match input")
+	}
+}
+
+func codecName(codec Codec) string {
+	switch codec.ID() {
+	case codecZlib:
+		return "zlib"
+	case codecZstd:
+		return "zstd"
+	case codecLZ4:
+		return "lz4"
+	case codecSnappy:
+		return "snappy"
+	case codecBrotli:
+		return "brotli"
+	default:
+		return "unknown"
+	}
+}
@@ -0,0 +1,272 @@
+package zseek
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+)
+
+// idxMagic identifies a seek-index sidecar file, written at the start of its trailer.
+const idxMagic = "ZSEEKIDX"
+
+// idxEntryLen is the on-disk size in bytes of one position entry in an index sidecar: two
+// little endian 64-bit integers, phys then virt. Entries are laid out back to back from the
+// start of the file so the whole array can be loaded with a single read (or mmapped) and
+// indexed directly, the way a zip central directory or a git .idx file is.
+const idxEntryLen = 16
+
+// idxTrailer is the fixed-size footer of a seek-index sidecar, immediately following the
+// entry array. Its size never changes, so it can always be found by seeking to
+// idxTrailerLen bytes before the end of the sidecar.
+type idxTrailer struct {
+	Magic      [8]byte
+	Codec      uint8
+	ChunkCount int64
+	TotalVirt  int64
+	PackEnd    int64
+	PackCRC32  uint32
+}
+
+// idxTrailerLen is the on-disk size in bytes of idxTrailer.
+const idxTrailerLen = 8 + 1 + 8 + 8 + 8 + 4
+
+// errIndexInvalid is returned by readIndex when idx is not a well-formed seek-index sidecar.
+// It is never returned to callers of OpenWithIndex, which treats it as "no sidecar to use"
+// and falls back to the lazy scan.
+var errIndexInvalid = errors.New("zseek: index sidecar is not valid")
+
+// OpenWithIndex is like New, but first attempts to load the seek index from idx instead of
+// rebuilding it lazily from pack as chunks happen to be read. If idx holds a trailer whose
+// PackEnd matches the current size of pack, the index is adopted as-is and the first
+// Seek(0, SeekEnd) on z is answered in O(1) reads instead of streaming every chunk through
+// seekEnd. If idx is empty, truncated, or stale (pack has grown since idx was written),
+// OpenWithIndex silently falls back to the same lazy scan New uses. A genuine error reading
+// idx (as opposed to it simply not holding a usable index yet) is returned to the caller
+// rather than being treated as "no sidecar".
+//
+// When a trailer is adopted, z.codec is also set back to whatever codec the trailer recorded,
+// so chunks written after reopening use the same codec as the rest of the pack instead of
+// silently reverting to New's default of ZlibCodec.
+//
+// Either way, idx is kept up to date: it is rewritten in full every time z.Flush or z.Close
+// writes a new chunk, so a later OpenWithIndex on the same pair of files sees the current
+// state. Use RebuildIndex to (re)create idx for a pack that was written without one.
+func OpenWithIndex(pack, idx io.ReadWriteSeeker) (*ZSeek, error) {
+	z, err := New(pack)
+	if err != nil {
+		return nil, err
+	}
+	z.idxFile = idx
+
+	entries, trailer, err := readIndex(idx)
+	if err != nil && err != errIndexInvalid {
+		return nil, err
+	}
+	if err != nil || trailer.PackEnd != z.end.phys {
+		if z.end.phys > 0 {
+			crc, err := rawCRC32(pack, z.end.phys)
+			if err != nil {
+				return nil, err
+			}
+			z.crc = crc
+
+			if _, err = pack.Seek(0, SeekStart); err != nil {
+				return nil, err
+			}
+		}
+		return z, nil
+	}
+
+	codec, ok := codecByID[trailer.Codec]
+	if !ok {
+		return nil, UnknownCodecError{ID: trailer.Codec}
+	}
+
+	z.idx = entries
+	z.end.virt = trailer.TotalVirt
+	z.crc = trailer.PackCRC32
+	z.codec = codec
+	return z, nil
+}
+
+// RebuildIndex scans pack from the beginning and writes a fresh seek-index sidecar to idx in
+// the format OpenWithIndex expects. Unlike the incremental updates ZSeek performs on Flush
+// and Close, RebuildIndex does not require having written pack with this package in the same
+// process; it is meant to (re)create idx for a pack file that has none, or whose sidecar was
+// lost or corrupted.
+func RebuildIndex(pack io.ReadSeeker, idx io.Writer) error {
+	entries, totalVirt, packEnd, crc, err := scanChunks(pack)
+	if err != nil {
+		return err
+	}
+
+	var codec uint8
+	if len(entries) > 0 {
+		if _, err = pack.Seek(entries[len(entries)-1].phys, SeekStart); err != nil {
+			return err
+		}
+		_, c, _, err := readChunkHeader(pack, entries[len(entries)-1].phys)
+		if err != nil {
+			return err
+		}
+		codec = c.ID()
+	}
+
+	return writeIndex(idx, entries, totalVirt, codec, packEnd, crc)
+}
+
+// readIndex reads and validates the trailer and entry array of an index sidecar. It returns
+// errIndexInvalid if idx is too short to contain a trailer or the trailer's magic or entry
+// count does not check out; a well-formed but stale trailer (one whose PackEnd no longer
+// matches the pack it accompanies) is not an error here, since staleness depends on the pack
+// the caller has open, not on idx alone.
+func readIndex(idx io.ReadSeeker) ([]position, idxTrailer, error) {
+	var trailer idxTrailer
+
+	end, err := idx.Seek(0, SeekEnd)
+	if err != nil {
+		return nil, trailer, err
+	}
+	if end < idxTrailerLen {
+		return nil, trailer, errIndexInvalid
+	}
+
+	if _, err = idx.Seek(end-idxTrailerLen, SeekStart); err != nil {
+		return nil, trailer, err
+	}
+	if err = binary.Read(idx, binary.LittleEndian, &trailer); err != nil {
+		return nil, trailer, err
+	}
+	if string(trailer.Magic[:]) != idxMagic {
+		return nil, trailer, errIndexInvalid
+	}
+	if trailer.ChunkCount < 0 || trailer.ChunkCount*idxEntryLen != end-idxTrailerLen {
+		return nil, trailer, errIndexInvalid
+	}
+
+	if _, err = idx.Seek(0, SeekStart); err != nil {
+		return nil, trailer, err
+	}
+	buf := make([]byte, trailer.ChunkCount*idxEntryLen)
+	if _, err = io.ReadFull(idx, buf); err != nil {
+		return nil, trailer, err
+	}
+
+	entries := make([]position, trailer.ChunkCount)
+	for i := range entries {
+		off := i * idxEntryLen
+		entries[i] = position{
+			phys: int64(binary.LittleEndian.Uint64(buf[off:])),
+			virt: int64(binary.LittleEndian.Uint64(buf[off+8:])),
+		}
+	}
+	return entries, trailer, nil
+}
+
+// writeIndex writes the full entry array followed by the trailer to idx, overwriting
+// whatever was there before. Since entries only ever grow between calls, this is always
+// safe to do in place without truncating idx first.
+func writeIndex(idx io.Writer, entries []position, totalVirt int64, codec uint8, packEnd int64, packCRC32 uint32) error {
+	buf := make([]byte, len(entries)*idxEntryLen)
+	for i, e := range entries {
+		off := i * idxEntryLen
+		binary.LittleEndian.PutUint64(buf[off:], uint64(e.phys))
+		binary.LittleEndian.PutUint64(buf[off+8:], uint64(e.virt))
+	}
+	if _, err := idx.Write(buf); err != nil {
+		return err
+	}
+
+	trailer := idxTrailer{
+		Codec:      codec,
+		ChunkCount: int64(len(entries)),
+		TotalVirt:  totalVirt,
+		PackEnd:    packEnd,
+		PackCRC32:  packCRC32,
+	}
+	copy(trailer.Magic[:], idxMagic)
+
+	return binary.Write(idx, binary.LittleEndian, trailer)
+}
+
+// syncIndex rewrites z.idxFile with the current index, if z was opened with one. It is
+// called after every successful Flush so that a sidecar never falls far behind the pack it
+// describes.
+func (z *ZSeek) syncIndex() error {
+	if z.idxFile == nil {
+		return nil
+	}
+	if _, err := z.idxFile.Seek(0, SeekStart); err != nil {
+		return err
+	}
+	return writeIndex(z.idxFile, z.idx, z.pos.virt, z.codec.ID(), z.pos.phys, z.crc)
+}
+
+// scanChunks walks every chunk of pack from the beginning, decompressing each one just far
+// enough to count its virtual size, and returns the same (phys, virt) entries ZSeek's lazy
+// scan would have produced, along with the pack's total virtual size, physical size, and
+// CRC32 of its raw (compressed) bytes.
+func scanChunks(pack io.ReadSeeker) (entries []position, totalVirt, packEnd int64, crc uint32, err error) {
+	if _, err = pack.Seek(0, SeekStart); err != nil {
+		return nil, 0, 0, 0, err
+	}
+
+	h := crc32.NewIEEE()
+	r := io.TeeReader(pack, h)
+
+	var pos position
+	for {
+		length, codec, chunkCRC, err := readChunkHeader(r, pos.phys)
+		if err == io.EOF {
+			return entries, pos.virt, pos.phys, h.Sum32(), nil
+		}
+		if err != nil {
+			return nil, 0, 0, 0, err
+		}
+
+		entries = append(entries, pos)
+
+		body := make([]byte, length)
+		if _, err = io.ReadFull(r, body); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, 0, 0, 0, err
+		}
+		if crc32.ChecksumIEEE(body) != chunkCRC {
+			return nil, 0, 0, 0, ChunkCorruptError{Phys: pos.phys}
+		}
+
+		cr, err := codec.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, 0, 0, 0, err
+		}
+		n, err := io.Copy(ioutil.Discard, cr)
+		if err != nil {
+			return nil, 0, 0, 0, err
+		}
+		if err = cr.Close(); err != nil {
+			return nil, 0, 0, 0, err
+		}
+
+		pos.phys += length + chunkHeaderLen
+		pos.virt += n
+	}
+}
+
+// rawCRC32 computes the CRC32 (IEEE) of the first n bytes of pack without interpreting them
+// as chunks, restoring pack's position to n afterwards. It is used to seed z.crc when
+// OpenWithIndex finds a pack with existing content but no usable sidecar.
+func rawCRC32(pack io.ReadSeeker, n int64) (uint32, error) {
+	if _, err := pack.Seek(0, SeekStart); err != nil {
+		return 0, err
+	}
+	h := crc32.NewIEEE()
+	if _, err := io.CopyN(h, pack, n); err != nil {
+		return 0, err
+	}
+	return h.Sum32(), nil
+}
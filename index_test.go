@@ -0,0 +1,211 @@
+package zseek
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+type memFile struct {
+	bytes.Buffer
+	pos int64
+}
+
+func (m *memFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case SeekCur:
+		offset += m.pos
+	case SeekEnd:
+		offset += int64(m.Buffer.Len())
+	}
+	if offset < 0 {
+		return 0, ErrInvalidSeek
+	}
+	m.pos = offset
+	return offset, nil
+}
+
+func (m *memFile) Read(p []byte) (int, error) {
+	if m.pos >= int64(m.Buffer.Len()) {
+		return 0, io.EOF
+	}
+	n := copy(p, m.Buffer.Bytes()[m.pos:])
+	m.pos += int64(n)
+	return n, nil
+}
+
+func (m *memFile) Write(p []byte) (int, error) {
+	if m.pos < int64(m.Buffer.Len()) {
+		b := m.Buffer.Bytes()
+		n := copy(b[m.pos:], p)
+		m.pos += int64(n)
+		if n == len(p) {
+			return n, nil
+		}
+		p = p[n:]
+	}
+	n, err := m.Buffer.Write(p)
+	m.pos += int64(n)
+	return n, err
+}
+
+func TestOpenWithIndex(t *testing.T) {
+	pack := &memFile{}
+	idx := &memFile{}
+
+	z, err := OpenWithIndex(pack, idx)
+	if err != nil {
+		t.Fatal("OpenWithIndex: ", err)
+	}
+
+	data := bytes.Repeat([]byte("hello, seekable world! "), 4096)
+	if _, err = z.Write(data); err != nil {
+		t.Fatal("Write: ", err)
+	}
+	if err = z.Close(); err != nil {
+		t.Fatal("Close: ", err)
+	}
+
+	if idx.Buffer.Len() == 0 {
+		t.Fatal("expected sidecar to be written, but it is empty")
+	}
+
+	z2, err := OpenWithIndex(pack, idx)
+	if err != nil {
+		t.Fatal("OpenWithIndex: ", err)
+	}
+	if len(z2.idx) == 0 {
+		t.Error("expected index to be loaded from sidecar, but it is empty")
+	}
+
+	o, err := z2.Seek(0, SeekEnd)
+	if err != nil {
+		t.Fatal("Seek: ", err)
+	}
+	if o != int64(len(data)) {
+		t.Error("expected offset ", len(data), ", but got offset ", o)
+	}
+
+	if _, err = z2.Seek(0, SeekStart); err != nil {
+		t.Fatal("Seek: ", err)
+	}
+	got := make([]byte, len(data))
+	if _, err = io.ReadFull(z2, got); err != nil {
+		t.Fatal("ReadFull: ", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Error("round-tripped data did not match input")
+	}
+}
+
+func TestOpenWithIndexPreservesCodec(t *testing.T) {
+	pack := &memFile{}
+	idx := &memFile{}
+
+	z, err := NewCodec(pack, ZstdCodec, DefaultCompression, DefaultBuffer)
+	if err != nil {
+		t.Fatal("NewCodec: ", err)
+	}
+	z.idxFile = idx
+
+	data := bytes.Repeat([]byte("zstd all the way down"), 4096)
+	if _, err = z.Write(data); err != nil {
+		t.Fatal("Write: ", err)
+	}
+	if err = z.Close(); err != nil {
+		t.Fatal("Close: ", err)
+	}
+
+	z2, err := OpenWithIndex(pack, idx)
+	if err != nil {
+		t.Fatal("OpenWithIndex: ", err)
+	}
+	if z2.codec.ID() != ZstdCodec.ID() {
+		t.Error("expected z2.codec to be ZstdCodec, but got id ", z2.codec.ID())
+	}
+
+	if _, err = z2.Seek(0, SeekEnd); err != nil {
+		t.Fatal("Seek: ", err)
+	}
+	more := bytes.Repeat([]byte("more data"), 100)
+	if _, err = z2.Write(more); err != nil {
+		t.Fatal("Write: ", err)
+	}
+	if err = z2.Close(); err != nil {
+		t.Fatal("Close: ", err)
+	}
+
+	entries, _, err := readIndex(idx)
+	if err != nil {
+		t.Fatal("readIndex: ", err)
+	}
+	lastPhys := entries[len(entries)-1].phys
+	if _, err = pack.Seek(lastPhys, SeekStart); err != nil {
+		t.Fatal("Seek: ", err)
+	}
+	_, codec, _, err := readChunkHeader(pack, lastPhys)
+	if err != nil {
+		t.Fatal("readChunkHeader: ", err)
+	}
+	if codec.ID() != ZstdCodec.ID() {
+		t.Error("expected the newly appended chunk to be tagged zstd, but got codec id ", codec.ID())
+	}
+}
+
+// erroringIdx wraps a memFile that already looks big enough to hold a trailer, but fails
+// every Read, so readIndex can't tell whether it's well-formed or not. It is used to confirm
+// OpenWithIndex propagates a genuine I/O error on idx instead of mistaking it for "no sidecar".
+type erroringIdx struct {
+	memFile
+}
+
+func (e *erroringIdx) Read(p []byte) (int, error) {
+	return 0, errors.New("erroringIdx: simulated read failure")
+}
+
+func TestOpenWithIndexPropagatesReadError(t *testing.T) {
+	pack := &memFile{}
+	idx := &erroringIdx{}
+	idx.Buffer.Write(make([]byte, idxTrailerLen))
+
+	_, err := OpenWithIndex(pack, idx)
+	if err == nil || err == errIndexInvalid {
+		t.Fatal("expected OpenWithIndex to propagate the read error, but got ", err)
+	}
+}
+
+func TestRebuildIndex(t *testing.T) {
+	pack := &memFile{}
+
+	z, err := New(pack)
+	if err != nil {
+		t.Fatal("New: ", err)
+	}
+	data := bytes.Repeat([]byte("rebuild me"), 1024)
+	if _, err = z.Write(data); err != nil {
+		t.Fatal("Write: ", err)
+	}
+	if err = z.Close(); err != nil {
+		t.Fatal("Close: ", err)
+	}
+
+	var idx memFile
+	if err = RebuildIndex(pack, &idx); err != nil {
+		t.Fatal("RebuildIndex: ", err)
+	}
+
+	entries, trailer, err := readIndex(&idx)
+	if err != nil {
+		t.Fatal("readIndex: ", err)
+	}
+	if len(entries) == 0 {
+		t.Error("expected at least one index entry")
+	}
+	if trailer.TotalVirt != int64(len(data)) {
+		t.Error("expected TotalVirt ", len(data), ", but got ", trailer.TotalVirt)
+	}
+	if trailer.PackEnd != int64(pack.Buffer.Len()) {
+		t.Error("expected PackEnd ", pack.Buffer.Len(), ", but got ", trailer.PackEnd)
+	}
+}
@@ -0,0 +1,126 @@
+package zseek
+
+import (
+	"bytes"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+)
+
+// Verify walks every chunk of z's pack from the beginning, checking that each chunk's stored
+// CRC32 matches its compressed bytes, the way git fsck walks a packfile's objects. It returns
+// the first ChunkCorruptError or ChunkVersionError encountered, identifying the chunk by the
+// physical offset of its header, or nil if every chunk checks out. Verify does not decompress
+// chunk bodies, so it is much cheaper than reading the whole file through Read or ReadAt.
+func (z *ZSeek) Verify() error {
+	if z.err != nil {
+		return z.err
+	}
+
+	z.ioMu.Lock()
+	defer z.ioMu.Unlock()
+
+	if _, err := z.f.Seek(0, SeekStart); err != nil {
+		return err
+	}
+	defer z.f.Seek(z.pos.phys, SeekStart)
+
+	var phys int64
+	for {
+		length, _, crc, err := readChunkHeader(z.f, phys)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		body := make([]byte, length)
+		if _, err = io.ReadFull(z.f, body); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return err
+		}
+		if crc32.ChecksumIEEE(body) != crc {
+			return ChunkCorruptError{Phys: phys}
+		}
+
+		phys += chunkHeaderLen + length
+	}
+}
+
+// Stats summarizes the chunks written to a ZSeek's pack, the way zip and other archive tools
+// surface size and compression-ratio diagnostics for their central directory entries.
+type Stats struct {
+	Chunks            int
+	CompressedBytes   int64
+	UncompressedBytes int64
+}
+
+// Ratio returns the average compressed/uncompressed size ratio across all chunks, or 0 if
+// UncompressedBytes is 0.
+func (s Stats) Ratio() float64 {
+	if s.UncompressedBytes == 0 {
+		return 0
+	}
+	return float64(s.CompressedBytes) / float64(s.UncompressedBytes)
+}
+
+// Stat walks every chunk of z's pack from the beginning, the same way Verify does, and
+// returns the number of chunks and their total compressed and uncompressed sizes. Like
+// Verify, it checks each chunk's CRC32 along the way and returns a ChunkCorruptError for the
+// first one that fails.
+func (z *ZSeek) Stat() (Stats, error) {
+	if z.err != nil {
+		return Stats{}, z.err
+	}
+
+	z.ioMu.Lock()
+	defer z.ioMu.Unlock()
+
+	if _, err := z.f.Seek(0, SeekStart); err != nil {
+		return Stats{}, err
+	}
+	defer z.f.Seek(z.pos.phys, SeekStart)
+
+	var stats Stats
+	var phys int64
+	for {
+		length, codec, crc, err := readChunkHeader(z.f, phys)
+		if err == io.EOF {
+			return stats, nil
+		}
+		if err != nil {
+			return Stats{}, err
+		}
+
+		body := make([]byte, length)
+		if _, err = io.ReadFull(z.f, body); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return Stats{}, err
+		}
+		if crc32.ChecksumIEEE(body) != crc {
+			return Stats{}, ChunkCorruptError{Phys: phys}
+		}
+
+		r, err := codec.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return Stats{}, err
+		}
+		n, err := io.Copy(ioutil.Discard, r)
+		if err != nil {
+			return Stats{}, err
+		}
+		if err = r.Close(); err != nil {
+			return Stats{}, err
+		}
+
+		stats.Chunks++
+		stats.CompressedBytes += length
+		stats.UncompressedBytes += n
+		phys += chunkHeaderLen + length
+	}
+}
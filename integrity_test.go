@@ -0,0 +1,57 @@
+package zseek
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestVerifyAndStat(t *testing.T) {
+	z, cleanup := testSetup(t)
+	defer cleanup()
+
+	data := bytes.Repeat([]byte("integrity check data"), 4096)
+	if _, err := z.Write(data); err != nil {
+		t.Fatal("Write: ", err)
+	}
+	if err := z.Flush(); err != nil {
+		t.Fatal("Flush: ", err)
+	}
+
+	if err := z.Verify(); err != nil {
+		t.Error("expected nil, but got ", err)
+	}
+
+	stats, err := z.Stat()
+	if err != nil {
+		t.Fatal("Stat: ", err)
+	}
+	if stats.Chunks <= 0 {
+		t.Error("expected at least 1 chunk, but got ", stats.Chunks)
+	}
+	if stats.UncompressedBytes != int64(len(data)) {
+		t.Error("expected ", len(data), " uncompressed bytes, but got ", stats.UncompressedBytes)
+	}
+	if stats.CompressedBytes <= 0 || stats.CompressedBytes >= stats.UncompressedBytes {
+		t.Error("expected compressed size smaller than uncompressed, but got ", stats.CompressedBytes)
+	}
+	if r := stats.Ratio(); r <= 0 || r >= 1 {
+		t.Error("expected a ratio between 0 and 1, but got ", r)
+	}
+
+	// flip a byte inside the chunk's compressed body to simulate corruption.
+	if _, err = z.f.Seek(chunkHeaderLen+4, SeekStart); err != nil {
+		t.Fatal("Seek: ", err)
+	}
+	if _, err = z.f.Write([]byte{0xff}); err != nil {
+		t.Fatal("Write: ", err)
+	}
+
+	err = z.Verify()
+	corrupt, ok := err.(ChunkCorruptError)
+	if !ok {
+		t.Fatal("expected a ChunkCorruptError, but got ", err)
+	}
+	if corrupt.Phys != 0 {
+		t.Error("expected ChunkCorruptError at offset 0, but got offset ", corrupt.Phys)
+	}
+}
@@ -0,0 +1,103 @@
+package zseek
+
+import (
+	"io"
+	"runtime"
+)
+
+// DefaultSubChunk is the default size, in bytes, of each independently-compressed piece a
+// NewParallel-created ZSeek splits its buffered writes into on Flush.
+const DefaultSubChunk = 256 * 1024
+
+// compressJob is one piece of pending work for a ZSeek's compressor worker pool: compress
+// data and send the result (or error) back on result.
+type compressJob struct {
+	data   []byte
+	result chan<- compressResult
+}
+
+// compressResult is the outcome of a compressJob.
+type compressResult struct {
+	data []byte
+	err  error
+}
+
+// NewParallel is like NewCodec, except Flush splits whatever is buffered into sub-chunks of
+// DefaultSubChunk bytes each and compresses them across a pool of workers goroutines instead
+// of serially on the calling goroutine, the way pigz splits a gzip stream across cores. Each
+// sub-chunk is written to disk as its own independently-decodable chunk, in order, so z.idx
+// ends up with one entry per sub-chunk rather than one per Flush call, improving seek
+// granularity for large writes. If workers is 0 or negative, runtime.GOMAXPROCS(0) is used.
+// Close stops and drains the worker pool.
+func NewParallel(f io.ReadWriteSeeker, codec Codec, level, buf, workers int) (*ZSeek, error) {
+	z, err := NewCodec(f, codec, level, buf)
+	if err != nil {
+		return nil, err
+	}
+
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	z.subChunk = DefaultSubChunk
+	z.jobs = make(chan compressJob)
+	z.workersWG.Add(workers)
+	for i := 0; i < workers; i++ {
+		go z.compressWorker()
+	}
+
+	return z, nil
+}
+
+// compressWorker services z.jobs until it is closed, one job at a time. It is run as one of
+// z's worker pool goroutines.
+func (z *ZSeek) compressWorker() {
+	defer z.workersWG.Done()
+
+	for job := range z.jobs {
+		data, err := z.compressChunk(job.data)
+		job.result <- compressResult{data: data, err: err}
+	}
+}
+
+// flushParallel compresses the first toWrite bytes of z.write as independent sub-chunks of
+// z.subChunk bytes, dispatched across z's worker pool, and writes the results out in order
+// once all of them are ready.
+func (z *ZSeek) flushParallel(toWrite int) error {
+	data := z.write.Bytes()[:toWrite]
+
+	var starts []int
+	for start := 0; start < len(data); start += z.subChunk {
+		starts = append(starts, start)
+	}
+
+	results := make([]chan compressResult, len(starts))
+	for i, start := range starts {
+		end := start + z.subChunk
+		if end > len(data) {
+			end = len(data)
+		}
+
+		ch := make(chan compressResult, 1)
+		results[i] = ch
+		z.jobs <- compressJob{data: data[start:end], result: ch}
+	}
+
+	virtStart := z.pos.virt - int64(toWrite)
+	for i, start := range starts {
+		end := start + z.subChunk
+		if end > len(data) {
+			end = len(data)
+		}
+
+		res := <-results[i]
+		if res.err != nil {
+			return res.err
+		}
+		if err := z.writeFramedChunk(res.data, virtStart); err != nil {
+			return err
+		}
+		virtStart += int64(end - start)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package zseek
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestParallel(t *testing.T) {
+	f, err := ioutil.TempFile(os.TempDir(), "zseek")
+	if err != nil {
+		t.Skip("temp file creation failed: ", err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	z, err := NewParallel(f, ZlibCodec, DefaultCompression, 1024*1024, 4)
+	if err != nil {
+		t.Fatal("NewParallel: ", err)
+	}
+
+	data := bytes.Repeat([]byte("parallel compression test data "), 64*1024/32)
+
+	if _, err = z.Write(data); err != nil {
+		t.Fatal("Write: ", err)
+	}
+	if err = z.Close(); err != nil {
+		t.Fatal("Close: ", err)
+	}
+
+	if got, want := len(z.idx), (len(data)+DefaultSubChunk-1)/DefaultSubChunk; got != want {
+		t.Error("expected ", want, " index entries (one per sub-chunk), but got ", got)
+	}
+
+	z2, err := New(f)
+	if err != nil {
+		t.Fatal("New: ", err)
+	}
+
+	got := make([]byte, len(data))
+	if _, err = io.ReadFull(z2, got); err != nil {
+		t.Fatal("ReadFull: ", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Error("round-tripped data did not match input")
+	}
+}
+
+// TestParallelWithCodec confirms NewParallel composes with a non-default codec, rather than
+// always writing zlib-compressed chunks regardless of the codec argument.
+func TestParallelWithCodec(t *testing.T) {
+	f, err := ioutil.TempFile(os.TempDir(), "zseek")
+	if err != nil {
+		t.Skip("temp file creation failed: ", err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	z, err := NewParallel(f, ZstdCodec, DefaultCompression, 1024*1024, 4)
+	if err != nil {
+		t.Fatal("NewParallel: ", err)
+	}
+
+	data := bytes.Repeat([]byte("parallel zstd test data "), 64*1024/24)
+	if _, err = z.Write(data); err != nil {
+		t.Fatal("Write: ", err)
+	}
+	if err = z.Close(); err != nil {
+		t.Fatal("Close: ", err)
+	}
+
+	if _, err = f.Seek(0, SeekStart); err != nil {
+		t.Fatal("Seek: ", err)
+	}
+	_, codec, _, err := readChunkHeader(f, 0)
+	if err != nil {
+		t.Fatal("readChunkHeader: ", err)
+	}
+	if codec.ID() != ZstdCodec.ID() {
+		t.Error("expected the first chunk to be tagged zstd, but got codec id ", codec.ID())
+	}
+
+	z2, err := New(f)
+	if err != nil {
+		t.Fatal("New: ", err)
+	}
+	got := make([]byte, len(data))
+	if _, err = io.ReadFull(z2, got); err != nil {
+		t.Fatal("ReadFull: ", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Error("round-tripped data did not match input")
+	}
+}
@@ -0,0 +1,196 @@
+package zseek
+
+import (
+	"bytes"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"sort"
+)
+
+// ReadAt implements io.ReaderAt, so a single *ZSeek can be read concurrently from many
+// goroutines without each one needing its own handle on the underlying file. Unlike Read,
+// ReadAt does not touch z's shared read cursor: each call independently locates and
+// decompresses the chunk(s) covering off..off+len(p) into a buffer of its own, taking z's
+// internal lock only for the short fetch of a chunk's raw compressed bytes from the
+// underlying file.
+//
+// ReadAt may be called concurrently with other calls to ReadAt. Like Read, Write, and Seek,
+// it is not safe to call concurrently with those, since they maintain z's sequential cursor.
+func (z *ZSeek) ReadAt(p []byte, off int64) (n int, err error) {
+	if z.err != nil {
+		return 0, z.err
+	}
+	if off < 0 {
+		return 0, ErrInvalidSeek
+	}
+
+	for n < len(p) {
+		var body []byte
+		var codec Codec
+		var base int64
+		body, codec, base, err = z.fetchChunk(off + int64(n))
+		if err != nil {
+			return n, err
+		}
+
+		var r io.ReadCloser
+		r, err = codec.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return n, err
+		}
+
+		if skip := off + int64(n) - base; skip > 0 {
+			if _, err = io.CopyN(ioutil.Discard, r, skip); err != nil {
+				r.Close()
+				return n, io.ErrUnexpectedEOF
+			}
+		}
+
+		var read int
+		read, err = io.ReadFull(r, p[n:])
+		n += read
+		if cerr := r.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+
+		switch err {
+		case nil:
+			// p is full; the loop condition will end us.
+		case io.EOF, io.ErrUnexpectedEOF:
+			// this chunk ended before p was filled; go around for the next one.
+			err = nil
+		default:
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// SectionReader returns an io.SectionReader that reads the n bytes of z starting at off,
+// the same way io.NewSectionReader is commonly used to hand out independent, concurrency-safe
+// views into a zip or pack file.
+func (z *ZSeek) SectionReader(off, n int64) *io.SectionReader {
+	return io.NewSectionReader(z, off, n)
+}
+
+// fetchChunk locks z briefly to locate and read the raw header and compressed body of the
+// chunk covering virtual offset virt, extending z.idx as needed. It returns the chunk's
+// compressed body, the codec to decompress it with, and the virtual offset of the first
+// byte of the chunk. The underlying file's position is restored before fetchChunk returns,
+// so it never disturbs a sequential Read, Write, or Seek that follows.
+func (z *ZSeek) fetchChunk(virt int64) (body []byte, codec Codec, base int64, err error) {
+	z.ioMu.Lock()
+	defer z.ioMu.Unlock()
+
+	if err = z.extendIndexLocked(virt); err != nil {
+		return nil, nil, 0, err
+	}
+
+	i := sort.Search(len(z.idx), func(i int) bool {
+		return z.idx[i].virt > virt
+	}) - 1
+	if i < 0 {
+		return nil, nil, 0, io.EOF
+	}
+	base = z.idx[i].virt
+
+	if _, err = z.f.Seek(z.idx[i].phys, SeekStart); err != nil {
+		return nil, nil, 0, err
+	}
+
+	length, c, crc, err := readChunkHeader(z.f, z.idx[i].phys)
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, nil, 0, err
+	}
+
+	body = make([]byte, length)
+	if _, err = io.ReadFull(z.f, body); err != nil {
+		return nil, nil, 0, err
+	}
+	if crc32.ChecksumIEEE(body) != crc {
+		return nil, nil, 0, ChunkCorruptError{Phys: z.idx[i].phys}
+	}
+
+	if _, err = z.f.Seek(z.pos.phys, SeekStart); err != nil {
+		return nil, nil, 0, err
+	}
+
+	return body, c, base, nil
+}
+
+// extendIndexLocked grows z.idx, and z.end.virt once it becomes known, until either an
+// entry is found covering virt or the whole file has been scanned and virt is out of range
+// (in which case it returns io.EOF). Callers must hold z.ioMu.
+func (z *ZSeek) extendIndexLocked(virt int64) error {
+	i := sort.Search(len(z.idx), func(i int) bool {
+		return z.idx[i].virt > virt
+	}) - 1
+	if i >= 0 && i+1 < len(z.idx) {
+		return nil
+	}
+	if z.end.virt != -1 {
+		if i < 0 || virt >= z.end.virt {
+			return io.EOF
+		}
+		return nil
+	}
+
+	pos := position{phys: 0, virt: 0}
+	if len(z.idx) > 0 {
+		pos = z.idx[len(z.idx)-1]
+	}
+	if _, err := z.f.Seek(pos.phys, SeekStart); err != nil {
+		return err
+	}
+
+	for {
+		length, codec, crc, err := readChunkHeader(z.f, pos.phys)
+		if err == io.EOF {
+			z.end.virt = pos.virt
+			if virt >= z.end.virt {
+				return io.EOF
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if len(z.idx) == 0 || z.idx[len(z.idx)-1].phys < pos.phys {
+			z.idx = append(z.idx, pos)
+		}
+
+		body := make([]byte, length)
+		if _, err = io.ReadFull(z.f, body); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return err
+		}
+		if crc32.ChecksumIEEE(body) != crc {
+			return ChunkCorruptError{Phys: pos.phys}
+		}
+
+		r, err := codec.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		n, err := io.Copy(ioutil.Discard, r)
+		if err != nil {
+			return err
+		}
+		if err = r.Close(); err != nil {
+			return err
+		}
+
+		next := position{phys: pos.phys + length + chunkHeaderLen, virt: pos.virt + n}
+		if pos.virt <= virt && virt < next.virt {
+			return nil
+		}
+		pos = next
+	}
+}
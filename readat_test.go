@@ -0,0 +1,85 @@
+package zseek
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"testing"
+)
+
+func TestReadAt(t *testing.T) {
+	z, cleanup := testSetup(t)
+	defer cleanup()
+
+	data := bytes.Repeat([]byte("0123456789"), 100*1024)
+	if _, err := z.Write(data); err != nil {
+		t.Fatal("Write: ", err)
+	}
+	if err := z.Flush(); err != nil {
+		t.Fatal("Flush: ", err)
+	}
+
+	var wg sync.WaitGroup
+	offsets := []int64{0, 1, 17, int64(len(data) / 2), int64(len(data) - 64)}
+	for _, off := range offsets {
+		off := off
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			got := make([]byte, 64)
+			n, err := z.ReadAt(got, off)
+			if err != nil && err != io.EOF {
+				t.Error("ReadAt(", off, "): ", err)
+				return
+			}
+			if !bytes.Equal(got[:n], data[off:off+int64(n)]) {
+				t.Error("ReadAt(", off, ") returned mismatched data")
+			}
+		}()
+	}
+	wg.Wait()
+
+	b := make([]byte, len(data))
+	n, err := z.ReadAt(b, 0)
+	if err != nil {
+		t.Error("expected nil, but got ", err)
+	}
+	if n != len(data) {
+		t.Error("expected ", len(data), " bytes, but got ", n, " bytes")
+	}
+	if !bytes.Equal(b, data) {
+		t.Error("ReadAt did not reproduce the written data")
+	}
+
+	short := make([]byte, 16)
+	n, err = z.ReadAt(short, int64(len(data)-8))
+	if err != io.EOF {
+		t.Error("expected io.EOF, but got ", err)
+	}
+	if n != 8 {
+		t.Error("expected 8 bytes, but got ", n, " bytes")
+	}
+}
+
+func TestSectionReader(t *testing.T) {
+	z, cleanup := testSetup(t)
+	defer cleanup()
+
+	data := bytes.Repeat([]byte("abcdefgh"), 8192)
+	if _, err := z.Write(data); err != nil {
+		t.Fatal("Write: ", err)
+	}
+	if err := z.Flush(); err != nil {
+		t.Fatal("Flush: ", err)
+	}
+
+	sr := z.SectionReader(10, 100)
+	got := make([]byte, 100)
+	if _, err := io.ReadFull(sr, got); err != nil {
+		t.Fatal("ReadFull: ", err)
+	}
+	if !bytes.Equal(got, data[10:110]) {
+		t.Error("SectionReader did not reproduce the expected slice of data")
+	}
+}
@@ -6,11 +6,24 @@ import (
 	"compress/zlib"
 	"encoding/binary"
 	"errors"
+	"hash/crc32"
 	"io"
 	"io/ioutil"
 	"sort"
+	"strconv"
+	"sync"
 )
 
+// chunkFormatVersion is the only chunk header layout this package knows how to read. It is
+// the first byte of every chunk header, so a pack written with some future, incompatible
+// header layout is rejected with ChunkVersionError instead of being silently misread.
+const chunkFormatVersion = 1
+
+// chunkHeaderLen is the size in bytes of the per-chunk header: a version byte, a 64-bit
+// little endian compressed length, a one-byte codec ID, and a CRC32 (IEEE) of the compressed
+// bytes that follow, which Verify (and fill, and ReadAt) check every chunk against.
+const chunkHeaderLen = 1 + 64/8 + 1 + 4
+
 // Constants for Seek
 const (
 	SeekStart = 0
@@ -37,24 +50,53 @@ var (
 	ErrInvalidSeek = errors.New("zseek: cannot seek outside of file")
 )
 
+// ChunkVersionError is returned when a chunk header's version byte is not chunkFormatVersion.
+type ChunkVersionError struct {
+	Version uint8
+}
+
+func (e ChunkVersionError) Error() string {
+	return "zseek: unsupported chunk format version " + strconv.Itoa(int(e.Version))
+}
+
+// ChunkCorruptError is returned by fill, ReadAt, or Verify when a chunk's stored CRC32 does
+// not match its compressed bytes, identifying the chunk by the physical offset of its header.
+type ChunkCorruptError struct {
+	Phys int64
+}
+
+func (e ChunkCorruptError) Error() string {
+	return "zseek: corrupt chunk at offset " + strconv.FormatInt(e.Phys, 10)
+}
+
 type position struct {
 	phys, virt int64
 }
 
-// ZSeek is a seekable compressed file. The file is written in chunks of zlib-compressed
-// data prefixed with a 64-bit little endian integer representing the compressed size of
-// the chunk. ZSeek can only be used as an io.Writer if it is at the end of the file.
-// Attempting to write before reaching the end of the file will return ErrEarlyWrite.
+// ZSeek is a seekable compressed file. The file is written in chunks of compressed data
+// prefixed with a 64-bit little endian integer representing the compressed size of the
+// chunk and a one-byte codec ID identifying how that chunk was compressed, so a file may
+// mix chunks written by different codecs across reopens. ZSeek can only be used as an
+// io.Writer if it is at the end of the file. Attempting to write before reaching the end of
+// the file will return ErrEarlyWrite.
 type ZSeek struct {
-	f     io.ReadWriteSeeker
-	read  bytes.Buffer
-	write bytes.Buffer
-	level int        // zlib compression level
-	idx   []position // both values are monotonically increasing
-	pos   position   // current position
-	end   position   // physical position is always set; virt is -1 until known
-	buf   int        // max length of write before Flush is called automatically
-	err   error      // unrecoverable error
+	f         io.ReadWriteSeeker
+	read      bytes.Buffer
+	write     bytes.Buffer
+	codec     Codec              // codec used to compress newly written chunks
+	level     int                // compression level passed to codec.NewWriter
+	idx       []position         // both values are monotonically increasing
+	pos       position           // current position
+	end       position           // physical position is always set; virt is -1 until known
+	buf       int                // max length of write before Flush is called automatically
+	err       error              // unrecoverable error
+	idxFile   io.ReadWriteSeeker // optional seek-index sidecar kept up to date by Flush; see OpenWithIndex
+	crc       uint32             // running CRC32 (IEEE) of the pack, valid only while idxFile != nil
+	ioMu      sync.Mutex         // guards z.f and z.idx against concurrent ReadAt calls; see readat.go
+	subChunk  int                // size of each independently-compressed piece on Flush; 0 disables parallel compression
+	jobs      chan compressJob   // compressor worker pool input, non-nil only if z was created with NewParallel; see parallel.go
+	workersWG sync.WaitGroup     // tracks the worker pool's goroutines, so Close can drain it
+	cache     *chunkCache        // LRU cache of decompressed chunks, consulted by fill; nil unless z was created with NewCached; see cache.go
 }
 
 // New is equivalent to calling NewBuffer(f, DefaultCompression, DefaultBuffer).
@@ -67,10 +109,17 @@ func NewLevel(f io.ReadWriteSeeker, level int) (*ZSeek, error) {
 	return NewBuffer(f, level, DefaultBuffer)
 }
 
-// NewBuffer creates a *ZSeek with a specified buffer size for writing. Whenever there are
-// at least buf bytes of unwritten data during a Write call, Flush will automatically be
-// called.
+// NewBuffer is equivalent to calling NewCodec(f, ZlibCodec, level, buf).
 func NewBuffer(f io.ReadWriteSeeker, level, buf int) (*ZSeek, error) {
+	return NewCodec(f, ZlibCodec, level, buf)
+}
+
+// NewCodec creates a *ZSeek that compresses newly written chunks with codec at the given
+// level, with a specified buffer size for writing. Whenever there are at least buf bytes of
+// unwritten data during a Write call, Flush will automatically be called. Existing chunks
+// are read with whichever codec they were written with, so a file may mix chunks from
+// different codecs across reopens with different codec arguments.
+func NewCodec(f io.ReadWriteSeeker, codec Codec, level, buf int) (*ZSeek, error) {
 	if buf <= 0 {
 		buf = DefaultBuffer
 	}
@@ -85,7 +134,7 @@ func NewBuffer(f io.ReadWriteSeeker, level, buf int) (*ZSeek, error) {
 		return nil, err
 	}
 
-	return &ZSeek{f: f, end: position{phys: end, virt: -1}, buf: buf, level: level}, nil
+	return &ZSeek{f: f, end: position{phys: end, virt: -1}, buf: buf, codec: codec, level: level}, nil
 }
 
 // Read implements io.Reader. If a read would cross a chunk boundary, a partial read is done
@@ -186,10 +235,36 @@ func (z *ZSeek) Seek(offset int64, whence int) (int64, error) {
 	return offset, nil
 }
 
+// recordChunkStart appends z.pos to z.idx if it isn't already the last entry, the way fill
+// notes the start of every chunk it reads, whether or not that chunk came from z.cache.
+func (z *ZSeek) recordChunkStart() {
+	if len(z.idx) == 0 || z.idx[len(z.idx)-1].phys < z.pos.phys {
+		z.idx = append(z.idx, z.pos)
+	}
+}
+
 func (z *ZSeek) fill() error {
-	var l int64
-	err := binary.Read(z.f, binary.LittleEndian, &l)
+	chunkPhys := z.pos.phys
+
+	if z.cache != nil {
+		z.ioMu.Lock()
+		data, nextPhys, ok := z.cache.get(chunkPhys)
+		z.ioMu.Unlock()
+		if ok {
+			z.recordChunkStart()
+			// a cache hit skips reading z.f, so its cursor must be moved to nextPhys by
+			// hand to keep it in sync with z.pos.phys for whatever read comes next.
+			if _, err := z.f.Seek(nextPhys, SeekStart); err != nil {
+				z.err = err
+				return err
+			}
+			z.pos.phys = nextPhys
+			z.read.Write(data)
+			return nil
+		}
+	}
 
+	length, codec, crc, err := readChunkHeader(z.f, chunkPhys)
 	if err != nil {
 		if err != io.EOF {
 			z.err = err
@@ -199,12 +274,24 @@ func (z *ZSeek) fill() error {
 		return err
 	}
 
-	if len(z.idx) == 0 || z.idx[len(z.idx)-1].phys < z.pos.phys {
-		z.idx = append(z.idx, z.pos)
+	z.recordChunkStart()
+
+	z.pos.phys += length + chunkHeaderLen
+
+	body := make([]byte, length)
+	if _, err = io.ReadFull(z.f, body); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		z.err = err
+		return err
+	}
+	if crc32.ChecksumIEEE(body) != crc {
+		z.err = ChunkCorruptError{Phys: chunkPhys}
+		return z.err
 	}
 
-	z.pos.phys += l + (64 / 8)
-	r, err := zlib.NewReader(io.LimitReader(z.f, l))
+	r, err := codec.NewReader(bytes.NewReader(body))
 	if err != nil {
 		z.err = err
 		return err
@@ -219,9 +306,58 @@ func (z *ZSeek) fill() error {
 		z.err = err
 		return err
 	}
+
+	if z.cache != nil {
+		cached := make([]byte, z.read.Len())
+		copy(cached, z.read.Bytes())
+		z.ioMu.Lock()
+		z.cache.put(chunkPhys, z.pos.phys, cached)
+		z.ioMu.Unlock()
+	}
 	return nil
 }
 
+// maxChunkLength is a sane upper bound on a single chunk's compressed length: far larger than
+// anything this package itself ever writes in one chunk, but small enough that a corrupted
+// length field can't be used to make fill, ReadAt, Verify, or Stat allocate a wild amount of
+// memory (or, since the field is read as a signed int64, panic in make([]byte, length))
+// before the length is ever checked against the chunk's CRC32.
+const maxChunkLength = 1 << 32
+
+// readChunkHeader reads and validates one chunk header from r: a version byte (checked
+// against chunkFormatVersion), a compressed length, a codec ID, and a CRC32 (IEEE) of the
+// compressed bytes that follow. It is used by fill, ReadAt, and the seek-index scanners, all
+// of which go on to read exactly length more bytes and should check them against crc. phys is
+// only used to identify the chunk in a returned ChunkCorruptError.
+func readChunkHeader(r io.Reader, phys int64) (length int64, codec Codec, crc uint32, err error) {
+	var header [chunkHeaderLen]byte
+	if _, err = io.ReadFull(r, header[:1]); err != nil {
+		return 0, nil, 0, err
+	}
+	if header[0] != chunkFormatVersion {
+		return 0, nil, 0, ChunkVersionError{Version: header[0]}
+	}
+
+	if _, err = io.ReadFull(r, header[1:]); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return 0, nil, 0, err
+	}
+
+	length = int64(binary.LittleEndian.Uint64(header[1:9]))
+	if length < 0 || length > maxChunkLength {
+		return 0, nil, 0, ChunkCorruptError{Phys: phys}
+	}
+
+	codec, ok := codecByID[header[9]]
+	if !ok {
+		return 0, nil, 0, UnknownCodecError{ID: header[9]}
+	}
+	crc = binary.LittleEndian.Uint32(header[10:14])
+	return length, codec, crc, nil
+}
+
 func (z *ZSeek) seekEnd() error {
 	for {
 		z.pos.virt += int64(z.read.Len())
@@ -249,7 +385,9 @@ func (z *ZSeek) skip(n int64) error {
 }
 
 // Flush writes any buffered data to the underlying io.ReadWriteSeeker. Flush is a no-op if
-// there is no data to be written.
+// there is no data to be written. If z was created with NewParallel, the buffered data is
+// split into sub-chunks and compressed across z's worker pool before being written out in
+// order; otherwise it is compressed as a single chunk on the calling goroutine.
 func (z *ZSeek) Flush() error {
 	if z.err != nil {
 		return z.err
@@ -258,59 +396,103 @@ func (z *ZSeek) Flush() error {
 	if toWrite == 0 {
 		return nil
 	}
-	var buf bytes.Buffer
-	w, err := zlib.NewWriterLevel(&buf, z.level)
+
+	var err error
+	if z.jobs != nil {
+		err = z.flushParallel(toWrite)
+	} else {
+		err = z.flushSerial(toWrite)
+	}
 	if err != nil {
 		z.err = err
 		return err
 	}
-	vn, err := io.Copy(w, &z.write)
+	z.write.Reset()
+
+	err = z.syncIndex()
 	if err != nil {
 		z.err = err
 		return err
 	}
-	if vn != int64(toWrite) {
-		z.err = io.ErrShortWrite
-		return z.err
-	}
-	err = w.Close()
+	return nil
+}
+
+// flushSerial compresses the first toWrite bytes of z.write as a single chunk on the calling
+// goroutine, the way Flush has always worked for z created by New, NewLevel, NewBuffer, or
+// NewCodec.
+func (z *ZSeek) flushSerial(toWrite int) error {
+	compressed, err := z.compressChunk(z.write.Bytes()[:toWrite])
 	if err != nil {
-		z.err = err
 		return err
 	}
+	return z.writeFramedChunk(compressed, z.pos.virt-int64(toWrite))
+}
 
-	toWrite = buf.Len()
+// compressChunk compresses data as a single independent chunk using z's codec and level.
+func (z *ZSeek) compressChunk(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := z.codec.NewWriter(&buf, z.level)
+	if err != nil {
+		return nil, err
+	}
+	n, err := w.Write(data)
+	if err != nil {
+		return nil, err
+	}
+	if n != len(data) {
+		return nil, io.ErrShortWrite
+	}
+	if err = w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
 
-	const n int64 = 64 / 8
-	err = binary.Write(z.f, binary.LittleEndian, int64(toWrite))
+// writeFramedChunk appends one already-compressed chunk, whose uncompressed bytes started
+// at virtual offset virtStart, to z.f and extends z.idx (and, if configured, the seek-index
+// sidecar and running pack CRC32) to match.
+func (z *ZSeek) writeFramedChunk(compressed []byte, virtStart int64) error {
+	var header [chunkHeaderLen]byte
+	header[0] = chunkFormatVersion
+	binary.LittleEndian.PutUint64(header[1:9], uint64(len(compressed)))
+	header[9] = z.codec.ID()
+	binary.LittleEndian.PutUint32(header[10:14], crc32.ChecksumIEEE(compressed))
+
+	_, err := z.f.Write(header[:])
 	if err != nil {
-		z.err = err
 		return err
 	}
 
-	pn, err := io.Copy(z.f, &buf)
+	pn, err := z.f.Write(compressed)
 	if err != nil {
-		z.err = err
 		return err
 	}
-	if pn != int64(toWrite) {
-		z.err = io.ErrShortWrite
-		return z.err
+	if pn != len(compressed) {
+		return io.ErrShortWrite
 	}
 
-	z.idx = append(z.idx, position{
-		phys: z.pos.phys,
-		virt: z.pos.virt - int64(vn),
-	})
-	z.pos.phys += n + pn
-	z.end.phys += n + pn
+	if z.idxFile != nil {
+		z.crc = crc32.Update(z.crc, crc32.IEEETable, header[:])
+		z.crc = crc32.Update(z.crc, crc32.IEEETable, compressed)
+	}
+
+	z.idx = append(z.idx, position{phys: z.pos.phys, virt: virtStart})
+	z.pos.phys += chunkHeaderLen + int64(pn)
+	z.end.phys += chunkHeaderLen + int64(pn)
 	return nil
 }
 
 // Close implements io.Closer. Close does not close the underlying io.ReadWriteSeeker. After
-// Close is called, any action on z will return io.ErrClosedPipe.
+// Close is called, any action on z will return io.ErrClosedPipe. If z was created with
+// NewParallel, Close also stops and drains its compressor worker pool.
 func (z *ZSeek) Close() error {
 	err := z.Flush()
 	z.err = io.ErrClosedPipe
+
+	if z.jobs != nil {
+		close(z.jobs)
+		z.workersWG.Wait()
+		z.jobs = nil
+	}
 	return err
 }